@@ -0,0 +1,152 @@
+package routing
+
+import (
+	"math"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
+)
+
+// bimodalEstimator estimates the success probability of forwarding an amount
+// on a channel by modeling the channel's local balance as a bimodal
+// distribution: most channels spend the bulk of their time sitting close to
+// one end or the other, rather than hovering in the middle. This tends to
+// produce better-calibrated probabilities than probabilityEstimator's decaying
+// apriori average, particularly for larger payments on channels that have
+// only been tried a handful of times.
+type bimodalEstimator struct {
+	// bimodalScaleMsat is the liquidity spread scale s used in the prior
+	// p(x) ∝ exp(-x/s) + exp(-(C-x)/s). Small values concentrate the
+	// prior mass at the two ends of [0, C], modeling strongly bimodal
+	// channels. Large values flatten the prior toward uniform.
+	bimodalScaleMsat lnwire.MilliSatoshi
+
+	// penaltyHalfLife defines after how much time the bounds learned from
+	// a prior success or failure relax back toward the uninformed prior
+	// of [0, capacity].
+	penaltyHalfLife time.Duration
+}
+
+// getPairProbability estimates the probability of successfully forwarding amt
+// to toNode over a channel of the given capacity, based on the most recent
+// payment result for that pair, if any.
+func (b *bimodalEstimator) getPairProbability(now time.Time,
+	results NodeResults, toNode route.Vertex,
+	amt, capacity lnwire.MilliSatoshi) float64 {
+
+	// With no capacity, we cannot reason about a balance distribution at
+	// all, so the pair is treated as unable to carry the amount. The same
+	// holds for an amount larger than the capacity.
+	if capacity == 0 {
+		return 0
+	}
+	if amt > capacity {
+		return 0
+	}
+
+	lastPairResult, ok := results[toNode]
+
+	// No history for this pair: the prior is the full, untruncated
+	// distribution over [0, capacity].
+	if !ok {
+		return b.tailProbability(0, capacity, amt, capacity)
+	}
+
+	// Start from the uninformed bounds and narrow them based on the last
+	// observed result.
+	lowerMsat := lnwire.MilliSatoshi(0)
+	upperMsat := capacity
+
+	if lastPairResult.Success {
+		// MinPenalizeAmt is only ever populated as a failure
+		// threshold (see probabilityEstimator.getPairProbability),
+		// not as "the amount that succeeded", so it can't be reused
+		// here. SuccessAmt carries the amount of the last successful
+		// payment to toNode and raises the lower bound to it, per the
+		// bimodal model's definition of a_s.
+		lowerMsat = lastPairResult.SuccessAmt
+	} else {
+		if lastPairResult.MinPenalizeAmt > 0 {
+			upperMsat = lastPairResult.MinPenalizeAmt - 1
+		} else {
+			upperMsat = 0
+		}
+	}
+
+	// Widen the bounds back toward the uninformed prior as the
+	// observation ages, so that the effect of a single data point decays
+	// over time just as it does for the apriori model. retain is 1 for a
+	// fresh observation, keeping the truncated bound as-is, and decays
+	// toward 0 as the observation ages, relaxing the bound back toward
+	// the uninformed [0, capacity] prior.
+	age := now.Sub(lastPairResult.Timestamp)
+	retain := b.retainFactor(age)
+
+	lowerMsat = lnwire.MilliSatoshi(
+		float64(lowerMsat) * retain,
+	)
+	upperMsat = capacity - lnwire.MilliSatoshi(
+		float64(capacity-upperMsat)*retain,
+	)
+
+	if lowerMsat > upperMsat {
+		lowerMsat, upperMsat = upperMsat, lowerMsat
+	}
+
+	return b.tailProbability(lowerMsat, upperMsat, amt, capacity)
+}
+
+// retainFactor returns a value in [0, 1] indicating how much of the
+// truncation learned from a past result should still be applied. It decays
+// in exactly the same exponential shape as probabilityEstimator.getWeight,
+// reaching 0.5 after penaltyHalfLife has elapsed.
+func (b *bimodalEstimator) retainFactor(age time.Duration) float64 {
+	exp := -age.Hours() / b.penaltyHalfLife.Hours()
+	return math.Pow(2, exp)
+}
+
+// tailProbability computes P(success | amt) = ∫_{max(amt,lower)}^{upper}
+// p(x) dx / ∫_{lower}^{upper} p(x) dx for the bimodal prior p(x) ∝
+// exp(-x/s) + exp(-(C-x)/s), truncated to [lower, upper].
+func (b *bimodalEstimator) tailProbability(lower,
+	upper, amt, capacity lnwire.MilliSatoshi) float64 {
+
+	if lower >= upper {
+		return 0
+	}
+
+	total := b.mass(lower, upper, capacity)
+	if total <= 0 {
+		return 0
+	}
+
+	tailLower := amt
+	if tailLower < lower {
+		tailLower = lower
+	}
+	if tailLower >= upper {
+		return 0
+	}
+
+	tail := b.mass(tailLower, upper, capacity)
+
+	return tail / total
+}
+
+// mass returns the closed-form integral of the bimodal prior between lower
+// and upper, given the channel capacity.
+func (b *bimodalEstimator) mass(lower, upper, capacity lnwire.MilliSatoshi) float64 {
+	s := float64(b.bimodalScaleMsat)
+	c := float64(capacity)
+
+	cdf := func(x float64) float64 {
+		return -s*math.Exp(-x/s) + s*math.Exp(-(c-x)/s)
+	}
+
+	return cdf(float64(upper)) - cdf(float64(lower))
+}
+
+// A compile-time check to ensure bimodalEstimator implements the Estimator
+// interface.
+var _ Estimator = (*bimodalEstimator)(nil)
@@ -0,0 +1,272 @@
+package routing
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
+)
+
+const bimodalTestTolerance = 1e-6
+
+func newTestBimodalEstimator() *bimodalEstimator {
+	return &bimodalEstimator{
+		bimodalScaleMsat: lnwire.MilliSatoshi(300_000_000),
+		penaltyHalfLife:  time.Hour,
+	}
+}
+
+// TestBimodalMassSymmetry checks the closed-form mass integral against the
+// symmetry the prior is defined to have: since p(x) = p(C-x), the mass of
+// the left half of [0, C] must equal the mass of the right half.
+func TestBimodalMassSymmetry(t *testing.T) {
+	e := newTestBimodalEstimator()
+	capacity := lnwire.MilliSatoshi(1_000_000_000)
+	half := capacity / 2
+
+	left := e.mass(0, half, capacity)
+	right := e.mass(half, capacity, capacity)
+
+	if math.Abs(left-right) > bimodalTestTolerance {
+		t.Fatalf("expected symmetric mass, got left=%v right=%v",
+			left, right)
+	}
+}
+
+// TestBimodalTailProbabilityEdgeCases exercises the boundary behavior of
+// tailProbability that the closed-form integral relies on call sites getting
+// right.
+func TestBimodalTailProbabilityEdgeCases(t *testing.T) {
+	e := newTestBimodalEstimator()
+	capacity := lnwire.MilliSatoshi(1_000_000_000)
+
+	// Sending nothing should always succeed: the tail from the lower
+	// bound covers the entire remaining mass.
+	if got := e.tailProbability(0, capacity, 0, capacity); got != 1 {
+		t.Fatalf("amt=0 should have probability 1, got %v", got)
+	}
+
+	// Sending the full upper bound leaves no mass in the tail.
+	if got := e.tailProbability(0, capacity, capacity, capacity); got != 0 {
+		t.Fatalf("amt=upper should have probability 0, got %v", got)
+	}
+
+	// An empty or inverted bound interval carries no information.
+	if got := e.tailProbability(capacity, 0, 0, capacity); got != 0 {
+		t.Fatalf("lower >= upper should have probability 0, got %v", got)
+	}
+
+	// Probability must decrease as the requested amount increases.
+	lowAmt := e.tailProbability(0, capacity, capacity/4, capacity)
+	highAmt := e.tailProbability(0, capacity, capacity/2, capacity)
+	if highAmt > lowAmt {
+		t.Fatalf("expected probability to decrease with amount: "+
+			"amt=C/4 -> %v, amt=C/2 -> %v", lowAmt, highAmt)
+	}
+}
+
+// TestBimodalGetPairProbabilityNoHistory checks that an untried pair falls
+// back to the full, untruncated prior.
+func TestBimodalGetPairProbabilityNoHistory(t *testing.T) {
+	e := newTestBimodalEstimator()
+	now := time.Now()
+	toNode := route.Vertex{}
+	capacity := lnwire.MilliSatoshi(1_000_000_000)
+
+	results := make(NodeResults)
+
+	got := e.getPairProbability(now, results, toNode, 0, capacity)
+	want := e.tailProbability(0, capacity, 0, capacity)
+	if math.Abs(got-want) > bimodalTestTolerance {
+		t.Fatalf("no-history probability mismatch: got %v, want %v",
+			got, want)
+	}
+}
+
+// TestBimodalGetPairProbabilitySuccessRaisesLowerBound asserts that a
+// recorded success raises the lower truncation bound to the amount that
+// succeeded, per the request that "a prior success sending amount a_s raises
+// the lower bound to a_s". With a fresh (zero-age) observation there is no
+// decay, so any probe at or below the successful amount must be certain to
+// succeed.
+func TestBimodalGetPairProbabilitySuccessRaisesLowerBound(t *testing.T) {
+	e := newTestBimodalEstimator()
+	now := time.Now()
+	toNode := route.Vertex{}
+	capacity := lnwire.MilliSatoshi(1_000_000_000)
+	successAmt := lnwire.MilliSatoshi(400_000_000)
+
+	results := NodeResults{
+		toNode: NodeResult{
+			Success:    true,
+			Timestamp:  now,
+			SuccessAmt: successAmt,
+		},
+	}
+
+	got := e.getPairProbability(now, results, toNode, successAmt/2, capacity)
+	if math.Abs(got-1) > bimodalTestTolerance {
+		t.Fatalf("probing below a known-successful amount should be "+
+			"certain, got %v", got)
+	}
+
+	// An untried pair must not already behave as if it had the benefit
+	// of this observation.
+	untried := e.getPairProbability(
+		now, make(NodeResults), toNode, successAmt/2, capacity,
+	)
+	if untried >= got {
+		t.Fatalf("expected the successful pair to score higher than "+
+			"an untried one: tried=%v, untried=%v", got, untried)
+	}
+}
+
+// TestBimodalGetPairProbabilityFailureLowersUpperBound asserts that a
+// recorded failure lowers the upper truncation bound below the amount that
+// failed, so a probe at that same amount is now impossible rather than merely
+// less likely.
+func TestBimodalGetPairProbabilityFailureLowersUpperBound(t *testing.T) {
+	e := newTestBimodalEstimator()
+	now := time.Now()
+	toNode := route.Vertex{}
+	capacity := lnwire.MilliSatoshi(1_000_000_000)
+	failAmt := lnwire.MilliSatoshi(400_000_000)
+
+	results := NodeResults{
+		toNode: NodeResult{
+			Success:        false,
+			Timestamp:      now,
+			MinPenalizeAmt: failAmt,
+		},
+	}
+
+	got := e.getPairProbability(now, results, toNode, failAmt, capacity)
+	if got != 0 {
+		t.Fatalf("probing at a known-failed amount should be "+
+			"impossible, got %v", got)
+	}
+}
+
+// TestBimodalGetPairProbabilityCapacityEdgeCases covers the degenerate
+// capacity inputs that getPairProbability must reject before reasoning about
+// a balance distribution.
+func TestBimodalGetPairProbabilityCapacityEdgeCases(t *testing.T) {
+	e := newTestBimodalEstimator()
+	now := time.Now()
+	toNode := route.Vertex{}
+	results := make(NodeResults)
+
+	if got := e.getPairProbability(now, results, toNode, 0, 0); got != 0 {
+		t.Fatalf("zero capacity should have probability 0, got %v", got)
+	}
+
+	capacity := lnwire.MilliSatoshi(1_000_000_000)
+	if got := e.getPairProbability(
+		now, results, toNode, capacity+1, capacity,
+	); got != 0 {
+		t.Fatalf("amount exceeding capacity should have probability "+
+			"0, got %v", got)
+	}
+}
+
+// TestBuildEstimator checks that BuildEstimator selects the implementation
+// named in EstimatorConfig, which is how operators choose between "apriori"
+// and "bimodal".
+func TestBuildEstimator(t *testing.T) {
+	cfg := &EstimatorConfig{
+		EstimatorName:    BimodalEstimatorName,
+		PenaltyHalfLife:  time.Hour,
+		BimodalScaleMsat: lnwire.MilliSatoshi(300_000_000),
+	}
+
+	est, err := BuildEstimator(cfg)
+	if err != nil {
+		t.Fatalf("BuildEstimator: %v", err)
+	}
+	if _, ok := est.(*bimodalEstimator); !ok {
+		t.Fatalf("expected *bimodalEstimator, got %T", est)
+	}
+
+	cfg.EstimatorName = AprioriEstimatorName
+	est, err = BuildEstimator(cfg)
+	if err != nil {
+		t.Fatalf("BuildEstimator: %v", err)
+	}
+	if _, ok := est.(*probabilityEstimator); !ok {
+		t.Fatalf("expected *probabilityEstimator, got %T", est)
+	}
+
+	cfg.EstimatorName = "made-up-estimator"
+	if _, err := BuildEstimator(cfg); err == nil {
+		t.Fatal("expected an error for an unknown estimator name")
+	}
+}
+
+// syntheticFailureSequence replays a sequence of failures at shrinking
+// amounts against toNode, simulating a channel whose usable liquidity is
+// being discovered through repeated probing. Each step overwrites the
+// previous result for toNode, so only the last, smallest failure is left in
+// the returned results.
+func syntheticFailureSequence(now time.Time, toNode route.Vertex,
+	capacity lnwire.MilliSatoshi, steps int) NodeResults {
+
+	results := make(NodeResults)
+
+	amt := capacity
+	for i := 0; i < steps; i++ {
+		amt = amt * 9 / 10
+
+		results[toNode] = NodeResult{
+			Success:        false,
+			Timestamp:      now,
+			MinPenalizeAmt: amt,
+		}
+	}
+
+	return results
+}
+
+// BenchmarkBimodalConvergence times building a synthetic sequence of
+// shrinking failures and evaluating the bimodal estimator's probability for a
+// fixed probe amount against the result. The estimate itself is not recorded,
+// and the estimate after each intermediate failure is not computed.
+func BenchmarkBimodalConvergence(b *testing.B) {
+	estimator := &bimodalEstimator{
+		bimodalScaleMsat: lnwire.MilliSatoshi(300_000_000),
+		penaltyHalfLife:  time.Hour,
+	}
+
+	now := time.Now()
+	toNode := route.Vertex{}
+	capacity := lnwire.MilliSatoshi(1_000_000_000)
+	probeAmt := lnwire.MilliSatoshi(100_000_000)
+
+	for n := 0; n < b.N; n++ {
+		results := syntheticFailureSequence(now, toNode, capacity, 20)
+		estimator.getPairProbability(now, results, toNode, probeAmt, capacity)
+	}
+}
+
+// BenchmarkAprioriConvergence is the probabilityEstimator counterpart to
+// BenchmarkBimodalConvergence, replaying the same synthetic failure sequence
+// so the running time of the two models can be compared directly.
+func BenchmarkAprioriConvergence(b *testing.B) {
+	estimator := &probabilityEstimator{
+		penaltyHalfLife:        time.Hour,
+		aprioriHopProbability:  0.6,
+		aprioriWeight:          0.5,
+		prevSuccessProbability: 0.95,
+	}
+
+	now := time.Now()
+	toNode := route.Vertex{}
+	capacity := lnwire.MilliSatoshi(1_000_000_000)
+	probeAmt := lnwire.MilliSatoshi(100_000_000)
+
+	for n := 0; n < b.N; n++ {
+		results := syntheticFailureSequence(now, toNode, capacity, 20)
+		estimator.getPairProbability(now, results, toNode, probeAmt, capacity)
+	}
+}
@@ -0,0 +1,79 @@
+package routing
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnwire"
+)
+
+const (
+	// AprioriEstimatorName is the name of the decaying-apriori-average
+	// Estimator implementation, backed by probabilityEstimator.
+	AprioriEstimatorName = "apriori"
+
+	// BimodalEstimatorName is the name of the bimodal-liquidity Estimator
+	// implementation, backed by bimodalEstimator.
+	BimodalEstimatorName = "bimodal"
+)
+
+// EstimatorConfig holds the operator-facing configuration needed to build
+// whichever probability Estimator mission control and path finding should
+// use. EstimatorName selects the implementation; the remaining fields only
+// apply to the implementation that consumes them.
+type EstimatorConfig struct {
+	// EstimatorName selects the Estimator implementation to build: either
+	// AprioriEstimatorName or BimodalEstimatorName.
+	EstimatorName string
+
+	// PenaltyHalfLife defines after how much time a penalized node,
+	// channel, or truncated bound is back at 50% probability. Used by
+	// both estimator implementations.
+	PenaltyHalfLife time.Duration
+
+	// AprioriHopProbability is the assumed success probability of a hop
+	// in a route when no other information is available. Only used by
+	// AprioriEstimatorName.
+	AprioriHopProbability float64
+
+	// AprioriWeight defines to what extent historical results should be
+	// extrapolated to untried connections. Only used by
+	// AprioriEstimatorName.
+	AprioriWeight float64
+
+	// PrevSuccessProbability is the assumed probability for node pairs
+	// that successfully relayed the previous attempt. Only used by
+	// AprioriEstimatorName.
+	PrevSuccessProbability float64
+
+	// BimodalScaleMsat is the liquidity spread scale used by the bimodal
+	// prior. Only used by BimodalEstimatorName.
+	BimodalScaleMsat lnwire.MilliSatoshi
+}
+
+// BuildEstimator constructs the Estimator selected by cfg.EstimatorName, so
+// that operators can pick between "apriori" and "bimodal" without the
+// mission-control/path-finding code that consumes Estimator needing to know
+// about either implementation directly.
+func BuildEstimator(cfg *EstimatorConfig) (Estimator, error) {
+	switch cfg.EstimatorName {
+	case "", AprioriEstimatorName:
+		return &probabilityEstimator{
+			penaltyHalfLife:        cfg.PenaltyHalfLife,
+			aprioriHopProbability:  cfg.AprioriHopProbability,
+			aprioriWeight:          cfg.AprioriWeight,
+			prevSuccessProbability: cfg.PrevSuccessProbability,
+		}, nil
+
+	case BimodalEstimatorName:
+		return &bimodalEstimator{
+			bimodalScaleMsat: cfg.BimodalScaleMsat,
+			penaltyHalfLife:  cfg.PenaltyHalfLife,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown probability estimator %q, "+
+			"must be %q or %q", cfg.EstimatorName,
+			AprioriEstimatorName, BimodalEstimatorName)
+	}
+}
@@ -8,6 +8,22 @@ import (
 	"github.com/lightningnetwork/lnd/routing/route"
 )
 
+// Estimator is the interface implemented by the various ways of estimating
+// the success probability of sending a payment through a node pair, given
+// that node pair's historical payment results. Implementations are free to
+// use as much or as little of that history as their model calls for, and may
+// take the channel capacity into account when a balance-aware model, such as
+// bimodalEstimator, requires it.
+type Estimator interface {
+	// getPairProbability estimates the probability of successfully
+	// traversing to toNode, sending amt, given the capacity of the
+	// channel(s) between the node pair and the historical results stored
+	// in results.
+	getPairProbability(now time.Time, results NodeResults,
+		toNode route.Vertex, amt lnwire.MilliSatoshi,
+		capacity lnwire.MilliSatoshi) float64
+}
+
 // probabilityEstimator returns node and pair probabilities based on historical
 // payment results.
 type probabilityEstimator struct {
@@ -111,10 +127,12 @@ func (p *probabilityEstimator) getWeight(age time.Duration) float64 {
 
 // getPairProbability estimates the probability of successfully traversing to
 // toNode based on historical payment outcomes for the from node. Those outcomes
-// are passed in via the results parameter.
+// are passed in via the results parameter. The capacity parameter is unused by
+// this apriori model, but is part of Estimator so that callers can select
+// between implementations without caring which ones need it.
 func (p *probabilityEstimator) getPairProbability(
-	now time.Time, results NodeResults,
-	toNode route.Vertex, amt lnwire.MilliSatoshi) float64 {
+	now time.Time, results NodeResults, toNode route.Vertex,
+	amt, capacity lnwire.MilliSatoshi) float64 {
 
 	// Retrieve the last pair outcome.
 	lastPairResult, ok := results[toNode]
@@ -153,3 +171,7 @@ func (p *probabilityEstimator) getPairProbability(
 
 	return probability
 }
+
+// A compile-time check to ensure probabilityEstimator implements the
+// Estimator interface.
+var _ Estimator = (*probabilityEstimator)(nil)
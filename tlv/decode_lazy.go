@@ -0,0 +1,295 @@
+package tlv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+// lazyEntry records where a single record's value lives within a stream that
+// was decoded lazily, without committing to actually decoding it.
+type lazyEntry struct {
+	// typ is the record's type.
+	typ Type
+
+	// length is the length, in bytes, of the record's value.
+	length uint64
+
+	// offset is the byte offset of the record's value within the
+	// underlying io.ReaderAt. It is only meaningful when LazyRecords was
+	// built from a seekable source.
+	offset int64
+
+	// value holds the record's raw value bytes when LazyRecords was built
+	// by buffering a non-seekable io.Reader. It is nil for types that
+	// were neither known to the Stream nor registered as interesting,
+	// since their bytes were discarded at decode time.
+	value []byte
+}
+
+// LazyRecords is returned by Stream.DecodeLazy. It gives callers cheap,
+// selective access to the records of a TLV stream without paying the cost of
+// invoking every record's decoder up front, while still enforcing the same
+// canonicalness and unknown-required-type rules as Decode.
+type LazyRecords struct {
+	stream *Stream
+
+	// readerAt is set when the stream backing this LazyRecords supports
+	// random access, in which case record values are read on demand
+	// rather than buffered.
+	readerAt io.ReaderAt
+
+	entries []lazyEntry
+	index   map[Type]int
+}
+
+// DecodeLazy parses the (type, length) header of every record in the stream
+// read from r, deferring the actual decoding of each record's value. If r
+// implements io.ReaderAt (as *bytes.Reader and os.File do), only the header
+// varints are read and each value's offset is recorded so it can be fetched
+// later with Get or Decode. If r also implements io.Seeker, as both of those
+// do, the stream is assumed to start at r's current position rather than
+// absolute offset 0, so callers may freely read a header off r with ordinary
+// Read calls before handing it to DecodeLazy. A source that implements
+// io.ReaderAt but not io.Seeker is assumed to have the stream start at offset
+// 0, since there is no portable way to ask it for a current position.
+// Otherwise r is consumed sequentially and the value bytes are buffered only
+// for types known to this Stream or previously registered via
+// Stream.Interesting; all other unknown records are discarded immediately,
+// exactly as Decode already does.
+//
+// As with Decode, canonical ordering and unknown required types are enforced
+// while walking the stream.
+func (s *Stream) DecodeLazy(r io.Reader) (*LazyRecords, error) {
+	if ra, ok := r.(io.ReaderAt); ok {
+		return s.decodeLazySeekable(ra)
+	}
+
+	return s.decodeLazyBuffered(r)
+}
+
+// decodeLazySeekable builds a LazyRecords by reading only the type and length
+// of each record from ra, recording the offset of each value without reading
+// it.
+func (s *Stream) decodeLazySeekable(ra io.ReaderAt) (*LazyRecords, error) {
+	lr := &LazyRecords{
+		stream:   s,
+		readerAt: ra,
+		index:    make(map[Type]int),
+	}
+
+	// If ra also tracks a current position (as *bytes.Reader and
+	// *os.File do), start from there instead of assuming the stream
+	// begins at absolute offset 0, so a caller that has already read a
+	// header off ra with ordinary Read calls doesn't have it silently
+	// re-parsed as part of the TLV stream.
+	var startOffset int64
+	if seeker, ok := ra.(io.Seeker); ok {
+		pos, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		startOffset = pos
+	}
+
+	var (
+		min       Type
+		offset    = startOffset
+		overflow  bool
+		buf       [8]byte
+		recordIdx int
+	)
+
+	for {
+		sr := io.NewSectionReader(ra, offset, math.MaxInt64-offset)
+
+		t, err := ReadVarInt(sr, &buf)
+		switch {
+		case err == io.EOF:
+			return lr, nil
+		case err != nil:
+			return nil, err
+		}
+
+		typ := Type(t)
+		if overflow || typ < min {
+			return nil, ErrStreamNotCanonical
+		}
+
+		length, err := ReadVarInt(sr, &buf)
+		switch {
+		case err == io.EOF:
+			return nil, io.ErrUnexpectedEOF
+		case err != nil:
+			return nil, err
+		}
+
+		if length > s.maxRecordSize {
+			return nil, ErrRecordTooLarge
+		}
+
+		// An unknown type that we're required to understand is fatal,
+		// exactly as in Stream.decode.
+		_, newIdx, ok := s.getRecord(typ, recordIdx)
+		if !ok && typ%2 == 0 {
+			return nil, ErrUnknownRequiredType(typ)
+		}
+		recordIdx = newIdx
+
+		// headerLen is how many bytes of the two varints we just read
+		// via sr, whose position is relative to offset.
+		headerLen, err := sr.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		valueOffset := offset + headerLen
+
+		lr.index[typ] = len(lr.entries)
+		lr.entries = append(lr.entries, lazyEntry{
+			typ:    typ,
+			length: length,
+			offset: valueOffset,
+		})
+
+		offset = valueOffset + int64(length)
+
+		if typ == math.MaxUint64 {
+			overflow = true
+		}
+		min = typ + 1
+	}
+}
+
+// decodeLazyBuffered builds a LazyRecords by consuming r sequentially,
+// buffering the value bytes of types known to the Stream or registered as
+// interesting via Stream.Interesting, and discarding all others.
+func (s *Stream) decodeLazyBuffered(r io.Reader) (*LazyRecords, error) {
+	lr := &LazyRecords{
+		stream: s,
+		index:  make(map[Type]int),
+	}
+
+	var (
+		min       Type
+		overflow  bool
+		buf       [8]byte
+		recordIdx int
+	)
+
+	for {
+		t, err := ReadVarInt(r, &buf)
+		switch {
+		case err == io.EOF:
+			return lr, nil
+		case err != nil:
+			return nil, err
+		}
+
+		typ := Type(t)
+		if overflow || typ < min {
+			return nil, ErrStreamNotCanonical
+		}
+
+		length, err := ReadVarInt(r, &buf)
+		switch {
+		case err == io.EOF:
+			return nil, io.ErrUnexpectedEOF
+		case err != nil:
+			return nil, err
+		}
+
+		if length > s.maxRecordSize {
+			return nil, ErrRecordTooLarge
+		}
+
+		// An unknown type that we're required to understand is fatal,
+		// exactly as in Stream.decode.
+		_, newIdx, known := s.getRecord(typ, recordIdx)
+		if !known && typ%2 == 0 {
+			return nil, ErrUnknownRequiredType(typ)
+		}
+		recordIdx = newIdx
+
+		_, interesting := s.interesting[typ]
+
+		var value []byte
+		if known || interesting {
+			value = make([]byte, length)
+			if _, err := io.ReadFull(r, value); err != nil {
+				if err == io.EOF {
+					return nil, io.ErrUnexpectedEOF
+				}
+				return nil, err
+			}
+		} else if _, err := io.CopyN(ioutil.Discard, r, int64(length)); err != nil {
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		lr.index[typ] = len(lr.entries)
+		lr.entries = append(lr.entries, lazyEntry{
+			typ:    typ,
+			length: length,
+			value:  value,
+		})
+
+		if typ == math.MaxUint64 {
+			overflow = true
+		}
+		min = typ + 1
+	}
+}
+
+// Get returns the raw value bytes of the record with the given type, if it
+// was present in the decoded stream. The returned bool is false if the type
+// was not present, or if it was present but its bytes were discarded because
+// it was neither known to the Stream nor marked interesting.
+func (l *LazyRecords) Get(typ Type) ([]byte, bool) {
+	idx, ok := l.index[typ]
+	if !ok {
+		return nil, false
+	}
+	entry := l.entries[idx]
+
+	if l.readerAt == nil {
+		if entry.value == nil {
+			return nil, false
+		}
+		return entry.value, true
+	}
+
+	// ReadAt may return io.EOF together with a complete read that ends
+	// at the end of the source, so only a short read or another error
+	// counts as a failure.
+	value := make([]byte, entry.length)
+	n, err := l.readerAt.ReadAt(value, entry.offset)
+	if n < len(value) || (err != nil && err != io.EOF) {
+		return nil, false
+	}
+
+	return value, true
+}
+
+// Decode fetches the raw bytes for typ via Get and runs them through the
+// decoder registered on the parent Stream for that type, writing the result
+// into val. It returns an error if typ was not present in the stream or if
+// the parent Stream has no decoder registered for it.
+func (l *LazyRecords) Decode(typ Type, val interface{}) error {
+	raw, ok := l.Get(typ)
+	if !ok {
+		return fmt.Errorf("tlv type %d not present in stream", typ)
+	}
+
+	rec, _, ok := l.stream.getRecord(typ, 0)
+	if !ok {
+		return fmt.Errorf("tlv type %d has no decoder registered on "+
+			"this stream", typ)
+	}
+
+	var buf [8]byte
+	return rec.decoder(bytes.NewReader(raw), val, &buf, uint64(len(raw)))
+}
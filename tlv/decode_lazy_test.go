@@ -0,0 +1,231 @@
+package tlv
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// rawBytesDecoder is a minimal Decoder that copies a record's raw value bytes
+// into *val.(*[]byte), used to register known records for these tests
+// without depending on the primitive record constructors.
+func rawBytesDecoder(r io.Reader, val interface{}, _ *[8]byte, l uint64) error {
+	b := val.(*[]byte)
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+	*b = buf
+	return nil
+}
+
+// rawEntry is a (type, value) pair used to hand-assemble a canonical TLV
+// stream for these tests.
+type rawEntry struct {
+	typ Type
+	val []byte
+}
+
+// encodeRawEntries serializes entries as a canonical TLV byte stream.
+func encodeRawEntries(t *testing.T, entries []rawEntry) []byte {
+	t.Helper()
+
+	var (
+		out bytes.Buffer
+		buf [8]byte
+	)
+	for _, e := range entries {
+		if err := WriteVarInt(&out, uint64(e.typ), &buf); err != nil {
+			t.Fatalf("WriteVarInt(type): %v", err)
+		}
+		if err := WriteVarInt(&out, uint64(len(e.val)), &buf); err != nil {
+			t.Fatalf("WriteVarInt(length): %v", err)
+		}
+		out.Write(e.val)
+	}
+
+	return out.Bytes()
+}
+
+// plainReader implements only io.Reader, hiding any io.ReaderAt the
+// underlying reader might otherwise expose, so tests can exercise
+// decodeLazyBuffered.
+type plainReader struct {
+	r io.Reader
+}
+
+func (p *plainReader) Read(b []byte) (int, error) {
+	return p.r.Read(b)
+}
+
+func TestDecodeLazySeekable(t *testing.T) {
+	data := encodeRawEntries(t, []rawEntry{
+		{typ: 2, val: []byte("hello")},
+		{typ: 5, val: []byte("world")},
+	})
+
+	s := &Stream{
+		records: []Record{
+			{typ: 2, decoder: rawBytesDecoder},
+		},
+		maxRecordSize: MaxRecordSize,
+	}
+
+	lr, err := s.DecodeLazy(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DecodeLazy: %v", err)
+	}
+
+	raw, ok := lr.Get(2)
+	if !ok || string(raw) != "hello" {
+		t.Fatalf("Get(2) = %q, %v; want \"hello\", true", raw, ok)
+	}
+
+	// Even though type 5 isn't registered with the Stream, a seekable
+	// source never discards anything, so its bytes are still reachable.
+	raw, ok = lr.Get(5)
+	if !ok || string(raw) != "world" {
+		t.Fatalf("Get(5) = %q, %v; want \"world\", true", raw, ok)
+	}
+
+	var dst []byte
+	if err := lr.Decode(2, &dst); err != nil {
+		t.Fatalf("Decode(2): %v", err)
+	}
+	if string(dst) != "hello" {
+		t.Fatalf("Decode(2) = %q; want \"hello\"", dst)
+	}
+
+	// Type 5 has no registered decoder, so Decode must fail even though
+	// Get succeeds for it.
+	if err := lr.Decode(5, &dst); err == nil {
+		t.Fatal("expected Decode(5) to fail for an unregistered type")
+	}
+
+	if _, ok := lr.Get(9); ok {
+		t.Fatal("Get should fail for a type absent from the stream")
+	}
+}
+
+// TestDecodeLazySeekableRespectsCurrentOffset checks that DecodeLazy, when
+// handed a reader that is both an io.ReaderAt and an io.Seeker, starts
+// parsing from the reader's current position rather than assuming the
+// stream begins at absolute offset 0. This models a caller that has already
+// read some header off r (e.g. a message type) via ordinary Read calls
+// before handing the rest of r to DecodeLazy.
+func TestDecodeLazySeekableRespectsCurrentOffset(t *testing.T) {
+	prefix := []byte("not part of the tlv stream")
+	data := encodeRawEntries(t, []rawEntry{
+		{typ: 2, val: []byte("hello")},
+	})
+
+	r := bytes.NewReader(append(prefix, data...))
+
+	// Consume the prefix with an ordinary Read, as a caller reading a
+	// fixed-size header off the stream would.
+	buf := make([]byte, len(prefix))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatalf("ReadFull(prefix): %v", err)
+	}
+
+	s := &Stream{
+		records: []Record{
+			{typ: 2, decoder: rawBytesDecoder},
+		},
+		maxRecordSize: MaxRecordSize,
+	}
+
+	lr, err := s.DecodeLazy(r)
+	if err != nil {
+		t.Fatalf("DecodeLazy: %v", err)
+	}
+
+	raw, ok := lr.Get(2)
+	if !ok || string(raw) != "hello" {
+		t.Fatalf("Get(2) = %q, %v; want \"hello\", true", raw, ok)
+	}
+}
+
+func TestDecodeLazyBuffered(t *testing.T) {
+	data := encodeRawEntries(t, []rawEntry{
+		{typ: 2, val: []byte("hello")},
+		{typ: 5, val: []byte("world")},
+		{typ: 7, val: []byte("skip me")},
+	})
+
+	s := &Stream{
+		records: []Record{
+			{typ: 2, decoder: rawBytesDecoder},
+		},
+		maxRecordSize: MaxRecordSize,
+	}
+	s.Interesting(5)
+
+	lr, err := s.DecodeLazy(&plainReader{r: bytes.NewReader(data)})
+	if err != nil {
+		t.Fatalf("DecodeLazy: %v", err)
+	}
+
+	// Known to the Stream: buffered regardless of Interesting.
+	raw, ok := lr.Get(2)
+	if !ok || string(raw) != "hello" {
+		t.Fatalf("Get(2) = %q, %v; want \"hello\", true", raw, ok)
+	}
+
+	// Unknown but marked interesting: buffered too.
+	raw, ok = lr.Get(5)
+	if !ok || string(raw) != "world" {
+		t.Fatalf("Get(5) = %q, %v; want \"world\", true", raw, ok)
+	}
+
+	// Unknown and not interesting: discarded, as Decode already does.
+	if _, ok := lr.Get(7); ok {
+		t.Fatal("Get(7) should fail for a discarded, uninteresting type")
+	}
+}
+
+func TestDecodeLazyUnknownRequiredType(t *testing.T) {
+	data := encodeRawEntries(t, []rawEntry{
+		{typ: 4, val: []byte("must understand")},
+	})
+
+	s := &Stream{maxRecordSize: MaxRecordSize}
+
+	if _, err := s.DecodeLazy(bytes.NewReader(data)); err != ErrUnknownRequiredType(4) {
+		t.Fatalf("seekable: got err %v, want ErrUnknownRequiredType(4)", err)
+	}
+
+	if _, err := s.DecodeLazy(&plainReader{r: bytes.NewReader(data)}); err != ErrUnknownRequiredType(4) {
+		t.Fatalf("buffered: got err %v, want ErrUnknownRequiredType(4)", err)
+	}
+}
+
+func TestStreamSetMaxRecordSize(t *testing.T) {
+	bigVal := bytes.Repeat([]byte{0xaa}, MaxRecordSize+1)
+	data := encodeRawEntries(t, []rawEntry{
+		{typ: 2, val: bigVal},
+	})
+
+	s := &Stream{
+		records: []Record{
+			{typ: 2, decoder: rawBytesDecoder},
+		},
+		maxRecordSize: MaxRecordSize,
+	}
+
+	if _, err := s.DecodeLazy(bytes.NewReader(data)); err != ErrRecordTooLarge {
+		t.Fatalf("got err %v, want ErrRecordTooLarge", err)
+	}
+
+	s.SetMaxRecordSize(uint64(len(bigVal)))
+
+	lr, err := s.DecodeLazy(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DecodeLazy after SetMaxRecordSize: %v", err)
+	}
+
+	raw, ok := lr.Get(2)
+	if !ok || !bytes.Equal(raw, bigVal) {
+		t.Fatal("Get(2) did not return the oversized record's value")
+	}
+}
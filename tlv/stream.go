@@ -36,6 +36,19 @@ func (t ErrUnknownRequiredType) Error() string {
 type Stream struct {
 	records []Record
 	buf     [8]byte
+
+	// maxRecordSize is the largest record value this Stream will accept
+	// while decoding. It defaults to the package-level MaxRecordSize, but
+	// can be raised via SetMaxRecordSize for streams that are known to
+	// carry larger custom records.
+	maxRecordSize uint64
+
+	// interesting lists the record types unknown to this Stream whose
+	// values DecodeLazy will buffer when decoding from a non-seekable
+	// io.Reader. Unknown types not present here, including every unknown
+	// type while it is nil, are discarded exactly as Decode already
+	// discards unknown records.
+	interesting map[Type]struct{}
 }
 
 // NewStream creates a new TLV Stream given an encoding codec, a decoding codec,
@@ -64,10 +77,35 @@ func NewStream(records ...Record) (*Stream, error) {
 	}
 
 	return &Stream{
-		records: records,
+		records:       records,
+		maxRecordSize: MaxRecordSize,
 	}, nil
 }
 
+// SetMaxRecordSize overrides the maximum size a single record's value is
+// permitted to have when decoding with this Stream, in place of the package's
+// default MaxRecordSize. It returns the Stream so that it can be chained onto
+// MustNewStream at construction time.
+func (s *Stream) SetMaxRecordSize(max uint64) *Stream {
+	s.maxRecordSize = max
+	return s
+}
+
+// Interesting marks the given types as ones whose raw value bytes should be
+// retained by DecodeLazy even when they aren't known to this Stream and the
+// underlying reader can't be read from at arbitrary offsets. Types that are
+// never marked interesting continue to be discarded immediately, as Decode
+// already does for every unknown record.
+func (s *Stream) Interesting(types ...Type) *Stream {
+	if s.interesting == nil {
+		s.interesting = make(map[Type]struct{}, len(types))
+	}
+	for _, typ := range types {
+		s.interesting[typ] = struct{}{}
+	}
+	return s
+}
+
 // MustNewStream creates a new TLV Stream given an encoding codec, a decoding
 // codec, and a set of known records. If an error is encountered in creating the
 // stream, this method will panic instead of returning the error.
@@ -216,7 +254,7 @@ func (s *Stream) decode(r io.Reader, parsedTypes TypeSet) (TypeSet, error) {
 		// prevents malicious encoders from causing us to allocate an
 		// unbounded amount of memory when decoding variable-sized
 		// fields.
-		if length > MaxRecordSize {
+		if length > s.maxRecordSize {
 			return nil, ErrRecordTooLarge
 		}
 
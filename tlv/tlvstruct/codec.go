@@ -0,0 +1,336 @@
+package tlvstruct
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+// recordBuilder constructs a tlv.Record for a single field of an addressable
+// struct value.
+type recordBuilder func(structVal reflect.Value) (tlv.Record, error)
+
+// fieldCodec pairs the wire type number assigned to a field with the logic
+// needed to turn that field into a Record.
+type fieldCodec struct {
+	typeNum tlv.Type
+	build   recordBuilder
+}
+
+// codec is the compiled representation of a tagged struct type. It is built
+// once per reflect.Type and cached, since walking a struct's fields via
+// reflection is comparatively expensive.
+type codec struct {
+	typ    reflect.Type
+	fields []fieldCodec
+}
+
+// stream builds a *tlv.Stream bound to the fields of structVal, which must be
+// an addressable value of the codec's struct type.
+func (c *codec) stream(structVal reflect.Value) (*tlv.Stream, error) {
+	records := make([]tlv.Record, 0, len(c.fields))
+	for _, f := range c.fields {
+		record, err := f.build(structVal)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	// NewStream re-asserts canonical ordering, which doubles as a sanity
+	// check that the codec was built correctly.
+	return tlv.NewStream(records...)
+}
+
+// buildCodec walks the exported fields of t, parses their `tlv` tags, and
+// compiles a fieldCodec for each one.
+func buildCodec(t reflect.Type) (*codec, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("tlvstruct: %s is not a struct", t)
+	}
+
+	var fields []fieldCodec
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		// Unexported fields are invisible to reflection-based
+		// encoding and are silently skipped, mirroring encoding/json.
+		if field.PkgPath != "" {
+			continue
+		}
+
+		tag, ok := field.Tag.Lookup(tagName)
+		if !ok {
+			continue
+		}
+
+		declaredNum, required, err := parseTag(tag)
+		if err != nil {
+			return nil, fmt.Errorf("tlvstruct: field %s of %s: %w",
+				field.Name, t, err)
+		}
+
+		// The declared number in the tag is used directly as the wire
+		// type, so that it matches whatever spec-fixed TLV number a
+		// field is documented to use (e.g. in lnwire). Its parity must
+		// already agree with required/optional, exactly as
+		// tlv.Stream.Decode enforces for unknown types: even types
+		// are required, odd types are optional. A mismatch is
+		// rejected at registration time rather than silently
+		// re-numbered, since that would make the tag lie about the
+		// byte actually sent on the wire.
+		typeNum := tlv.Type(declaredNum)
+		switch {
+		case required && typeNum%2 != 0:
+			return nil, fmt.Errorf("tlvstruct: field %s of %s: "+
+				"required field must use an even tlv type, "+
+				"got %d", field.Name, t, typeNum)
+		case !required && typeNum%2 == 0:
+			return nil, fmt.Errorf("tlvstruct: field %s of %s: "+
+				"optional field must use an odd tlv type, "+
+				"got %d", field.Name, t, typeNum)
+		}
+
+		build, err := fieldBuilder(field, i, typeNum)
+		if err != nil {
+			return nil, fmt.Errorf("tlvstruct: field %s of %s: %w",
+				field.Name, t, err)
+		}
+
+		fields = append(fields, fieldCodec{
+			typeNum: typeNum,
+			build:   build,
+		})
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].typeNum < fields[j].typeNum
+	})
+
+	// Reject duplicate type numbers up front, mirroring the canonical
+	// ordering check NewStream performs on a fully-built record set.
+	for i := 1; i < len(fields); i++ {
+		if fields[i].typeNum == fields[i-1].typeNum {
+			return nil, fmt.Errorf("tlvstruct: %s has two fields "+
+				"mapping to tlv type %d", t, fields[i].typeNum)
+		}
+	}
+
+	return &codec{
+		typ:    t,
+		fields: fields,
+	}, nil
+}
+
+// parseTag parses a `tlv:"<type number>,<required|optional>"` tag.
+func parseTag(tag string) (uint64, bool, error) {
+	parts := strings.Split(tag, ",")
+	if len(parts) != 2 {
+		return 0, false, fmt.Errorf("malformed tlv tag %q, expected "+
+			"\"<type>,<required|optional>\"", tag)
+	}
+
+	num, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid tlv type in tag %q: %w",
+			tag, err)
+	}
+
+	switch strings.TrimSpace(parts[1]) {
+	case "required":
+		return num, true, nil
+	case "optional":
+		return num, false, nil
+	default:
+		return 0, false, fmt.Errorf("tlv tag %q must specify "+
+			"\"required\" or \"optional\"", tag)
+	}
+}
+
+// fieldBuilder returns the recordBuilder appropriate for the kind of field at
+// index i, mapping primitive Go kinds onto the existing primitive record
+// constructors in the tlv package and recursing into named struct types.
+func fieldBuilder(field reflect.StructField, i int,
+	typeNum tlv.Type) (recordBuilder, error) {
+
+	switch field.Type.Kind() {
+	case reflect.Uint8:
+		return func(sv reflect.Value) (tlv.Record, error) {
+			return tlv.MakeUint8Record(
+				typeNum, sv.Field(i).Addr().Interface().(*uint8),
+			), nil
+		}, nil
+
+	case reflect.Uint16:
+		return func(sv reflect.Value) (tlv.Record, error) {
+			return tlv.MakeUint16Record(
+				typeNum, sv.Field(i).Addr().Interface().(*uint16),
+			), nil
+		}, nil
+
+	case reflect.Uint32:
+		return func(sv reflect.Value) (tlv.Record, error) {
+			return tlv.MakeUint32Record(
+				typeNum, sv.Field(i).Addr().Interface().(*uint32),
+			), nil
+		}, nil
+
+	case reflect.Uint64:
+		return func(sv reflect.Value) (tlv.Record, error) {
+			return tlv.MakeUint64Record(
+				typeNum, sv.Field(i).Addr().Interface().(*uint64),
+			), nil
+		}, nil
+
+	case reflect.Bool:
+		return func(sv reflect.Value) (tlv.Record, error) {
+			return tlv.MakeBoolRecord(
+				typeNum, sv.Field(i).Addr().Interface().(*bool),
+			), nil
+		}, nil
+
+	case reflect.Array:
+		if field.Type.Elem().Kind() != reflect.Uint8 {
+			return nil, fmt.Errorf("unsupported array element "+
+				"type %s, only [N]byte is supported",
+				field.Type.Elem())
+		}
+		return func(sv reflect.Value) (tlv.Record, error) {
+			return tlv.MakeStaticBytesRecord(
+				typeNum, sv.Field(i).Addr().Interface(),
+			), nil
+		}, nil
+
+	case reflect.Slice:
+		if field.Type.Elem().Kind() != reflect.Uint8 {
+			return nil, fmt.Errorf("unsupported slice element "+
+				"type %s, only []byte is supported",
+				field.Type.Elem())
+		}
+		return func(sv reflect.Value) (tlv.Record, error) {
+			fv := sv.Field(i)
+			return tlv.MakeDynamicRecord(
+				typeNum, nil,
+				func() uint64 { return uint64(fv.Len()) },
+				func(w io.Writer, _ interface{}, _ *[8]byte) error {
+					_, err := w.Write(fv.Bytes())
+					return err
+				},
+				func(r io.Reader, _ interface{}, _ *[8]byte,
+					l uint64) error {
+
+					// An empty value decodes to a nil slice, the
+					// zero value it is encoded from.
+					if l == 0 {
+						fv.SetBytes(nil)
+						return nil
+					}
+
+					b := make([]byte, l)
+					if _, err := io.ReadFull(r, b); err != nil {
+						return err
+					}
+					fv.SetBytes(b)
+					return nil
+				},
+			), nil
+		}, nil
+
+	case reflect.String:
+		return func(sv reflect.Value) (tlv.Record, error) {
+			fv := sv.Field(i)
+			return tlv.MakeDynamicRecord(
+				typeNum, nil,
+				func() uint64 { return uint64(len(fv.String())) },
+				func(w io.Writer, _ interface{}, _ *[8]byte) error {
+					_, err := io.WriteString(w, fv.String())
+					return err
+				},
+				func(r io.Reader, _ interface{}, _ *[8]byte,
+					l uint64) error {
+
+					b := make([]byte, l)
+					if _, err := io.ReadFull(r, b); err != nil {
+						return err
+					}
+					fv.SetString(string(b))
+					return nil
+				},
+			), nil
+		}, nil
+
+	case reflect.Struct:
+		nested, err := getCodec(field.Type)
+		if err != nil {
+			return nil, err
+		}
+		return nestedStructBuilder(nested, i, typeNum), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported field kind %s",
+			field.Type.Kind())
+	}
+}
+
+// nestedStructBuilder returns a recordBuilder that serializes the struct
+// field at index i as its own nested TLV stream, allowing tagged structs to
+// be composed.
+func nestedStructBuilder(nested *codec, i int, typeNum tlv.Type) recordBuilder {
+	return func(sv reflect.Value) (tlv.Record, error) {
+		fv := sv.Field(i)
+
+		return tlv.MakeDynamicRecord(
+			typeNum, nil,
+			func() uint64 {
+				var buf sizeCountingWriter
+				if err := encodeNested(nested, fv, &buf); err != nil {
+					return 0
+				}
+				return buf.n
+			},
+			func(w io.Writer, _ interface{}, _ *[8]byte) error {
+				return encodeNested(nested, fv, w)
+			},
+			func(r io.Reader, _ interface{}, _ *[8]byte,
+				l uint64) error {
+
+				lr := io.LimitReader(r, int64(l))
+				return decodeNested(nested, fv, lr)
+			},
+		), nil
+	}
+}
+
+// encodeNested encodes the nested struct value v using its codec.
+func encodeNested(c *codec, v reflect.Value, w io.Writer) error {
+	stream, err := c.stream(v)
+	if err != nil {
+		return err
+	}
+	return stream.Encode(w)
+}
+
+// decodeNested decodes into the nested struct value v using its codec.
+func decodeNested(c *codec, v reflect.Value, r io.Reader) error {
+	stream, err := c.stream(v)
+	if err != nil {
+		return err
+	}
+	return stream.Decode(r)
+}
+
+// sizeCountingWriter discards written bytes while tracking how many were
+// written, used to compute the encoded size of a nested stream ahead of time.
+type sizeCountingWriter struct {
+	n uint64
+}
+
+func (s *sizeCountingWriter) Write(p []byte) (int, error) {
+	s.n += uint64(len(p))
+	return len(p), nil
+}
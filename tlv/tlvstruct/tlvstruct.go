@@ -0,0 +1,164 @@
+// Package tlvstruct derives tlv.Stream encoders and decoders from tagged Go
+// structs, similar in spirit to the struct-tag driven encoders found in other
+// serialization packages in the ecosystem (e.g. RLP). Instead of hand-wiring
+// a Record for every field of every message, callers can annotate a struct
+// with `tlv:"<type>,<required|optional>"` tags and use Marshal/Unmarshal to
+// drive a generated Stream.
+//
+// The number in the tag is used verbatim as the wire type, exactly as it
+// would be passed to a hand-written Record constructor, so it should be set
+// to whatever TLV type a field is actually specified to use (e.g. a message's
+// BOLT-documented TLV number), not a locally-invented index. As with the
+// rest of the tlv package, a field's required/optional-ness is carried by
+// the parity of that number: required fields must use an even type, optional
+// fields an odd one. A tag whose parity disagrees with its
+// required/optional keyword is rejected when the codec for the struct is
+// built, e.g.:
+//
+//	type Record struct {
+//	    Amount  uint64 `tlv:"2,required"` // wire type 2 (even => required)
+//	    Memo    string `tlv:"3,optional"` // wire type 3 (odd => optional)
+//	}
+package tlvstruct
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// tagName is the struct tag key inspected by this package.
+const tagName = "tlv"
+
+// codecCache memoizes the codec built for a given reflect.Type so that the
+// (potentially expensive) reflection walk only happens once per struct type.
+var (
+	codecCacheMu sync.Mutex
+	codecCache   = make(map[reflect.Type]*codec)
+)
+
+// Marshal encodes v, which must be a struct or a pointer to a struct whose
+// exported fields carry `tlv` tags, as a canonical TLV stream written to w.
+func Marshal(w io.Writer, v interface{}) error {
+	val, err := marshalValue(v)
+	if err != nil {
+		return err
+	}
+
+	c, err := getCodec(val.Type())
+	if err != nil {
+		return err
+	}
+
+	stream, err := c.stream(val)
+	if err != nil {
+		return err
+	}
+
+	return stream.Encode(w)
+}
+
+// Unmarshal decodes a TLV stream from r into v, which must be a non-nil
+// pointer to a struct whose exported fields carry `tlv` tags.
+func Unmarshal(r io.Reader, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("tlvstruct: Unmarshal requires a non-nil " +
+			"pointer to a struct")
+	}
+
+	val, err := structValue(v)
+	if err != nil {
+		return err
+	}
+
+	c, err := getCodec(val.Type())
+	if err != nil {
+		return err
+	}
+
+	stream, err := c.stream(val)
+	if err != nil {
+		return err
+	}
+
+	return stream.Decode(r)
+}
+
+// structValue dereferences v as needed and returns the addressable struct
+// value underlying it. It is used by Unmarshal, which writes back into v and
+// therefore genuinely requires a pointer.
+func structValue(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return reflect.Value{}, fmt.Errorf("tlvstruct: nil " +
+				"pointer passed")
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("tlvstruct: expected "+
+			"struct or pointer to struct, got %s", rv.Kind())
+	}
+	if !rv.CanAddr() {
+		return reflect.Value{}, fmt.Errorf("tlvstruct: value is not " +
+			"addressable, pass a pointer")
+	}
+
+	return rv, nil
+}
+
+// marshalValue dereferences v as needed and returns an addressable struct
+// value underlying it, suitable for Marshal. Marshal only ever reads fields,
+// so unlike structValue it does not require v itself to be addressable: a
+// plain struct value (as opposed to a pointer to one) is copied into a fresh
+// addressable value rather than being rejected, since reflect.Value built
+// from a bare struct is never addressable in Go.
+func marshalValue(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return reflect.Value{}, fmt.Errorf("tlvstruct: nil " +
+				"pointer passed")
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("tlvstruct: expected "+
+			"struct or pointer to struct, got %s", rv.Kind())
+	}
+
+	if !rv.CanAddr() {
+		addressable := reflect.New(rv.Type()).Elem()
+		addressable.Set(rv)
+		rv = addressable
+	}
+
+	return rv, nil
+}
+
+// getCodec returns the cached codec for t, building and caching it on first
+// use.
+func getCodec(t reflect.Type) (*codec, error) {
+	codecCacheMu.Lock()
+	c, ok := codecCache[t]
+	codecCacheMu.Unlock()
+	if ok {
+		return c, nil
+	}
+
+	c, err := buildCodec(t)
+	if err != nil {
+		return nil, err
+	}
+
+	codecCacheMu.Lock()
+	codecCache[t] = c
+	codecCacheMu.Unlock()
+
+	return c, nil
+}
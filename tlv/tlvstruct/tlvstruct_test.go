@@ -0,0 +1,111 @@
+package tlvstruct
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type nestedMsg struct {
+	Label string `tlv:"2,required"`
+}
+
+type testMsg struct {
+	Amount  uint64    `tlv:"2,required"`
+	Memo    string    `tlv:"3,optional"`
+	Data    []byte    `tlv:"5,optional"`
+	ID      [4]byte   `tlv:"6,required"`
+	Active  bool      `tlv:"8,required"`
+	Details nestedMsg `tlv:"11,optional"`
+
+	// unexported is here to assert that fields reflection can't see are
+	// silently skipped rather than erroring.
+	unexported int
+}
+
+func TestMarshalUnmarshalRoundTrip(t *testing.T) {
+	in := testMsg{
+		Amount: 100000,
+		Memo:   "coffee",
+		Data:   []byte{1, 2, 3, 4},
+		ID:     [4]byte{0xde, 0xad, 0xbe, 0xef},
+		Active: true,
+		Details: nestedMsg{
+			Label: "nested",
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Marshal(&buf, &in); err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var out testMsg
+	if err := Unmarshal(&buf, &out); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if !reflect.DeepEqual(out, in) {
+		t.Fatalf("round trip mismatch: got %+v, want %+v", out, in)
+	}
+}
+
+func TestMarshalAcceptsPlainStruct(t *testing.T) {
+	in := testMsg{
+		Amount: 42,
+		ID:     [4]byte{1, 2, 3, 4},
+	}
+
+	var buf bytes.Buffer
+	if err := Marshal(&buf, in); err != nil {
+		t.Fatalf("Marshal(plain struct): %v", err)
+	}
+
+	var out testMsg
+	if err := Unmarshal(&buf, &out); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if !reflect.DeepEqual(out, in) {
+		t.Fatalf("round trip mismatch: got %+v, want %+v", out, in)
+	}
+}
+
+type dupTypeMsg struct {
+	A uint8 `tlv:"2,required"`
+	B uint8 `tlv:"2,required"`
+}
+
+func TestDuplicateTypeNumberRejected(t *testing.T) {
+	var buf bytes.Buffer
+	err := Marshal(&buf, &dupTypeMsg{})
+	if err == nil {
+		t.Fatal("expected error for duplicate tlv type numbers")
+	}
+}
+
+type malformedTagMsg struct {
+	A uint8 `tlv:"not-a-number,required"`
+}
+
+func TestMalformedTagRejected(t *testing.T) {
+	var buf bytes.Buffer
+	err := Marshal(&buf, &malformedTagMsg{})
+	if err == nil {
+		t.Fatal("expected error for malformed tlv tag")
+	}
+}
+
+type parityMismatchMsg struct {
+	// Required fields must use an even wire type; this is required but
+	// tagged with an odd one.
+	A uint8 `tlv:"3,required"`
+}
+
+func TestParityMismatchRejected(t *testing.T) {
+	var buf bytes.Buffer
+	err := Marshal(&buf, &parityMismatchMsg{})
+	if err == nil {
+		t.Fatal("expected error for required field tagged with an odd type")
+	}
+}